@@ -94,6 +94,7 @@ func TestCloudLoggerResourceTypeAndLabels(t *testing.T) {
 	for _, tc := range []struct {
 		description        string
 		metadata           *fakeGCEMetadata
+		env                map[string]string
 		wantResourceType   string
 		wantResourceLabels map[string]string
 		wantLogEntryLabels map[string]string
@@ -137,8 +138,114 @@ func TestCloudLoggerResourceTypeAndLabels(t *testing.T) {
 				"cluster_name": "secret-bioweapons-lab-prod-1",
 			},
 		},
+		{
+			description: "Cloud Run revisions use cloud_run_revision",
+			metadata: &fakeGCEMetadata{
+				projectID: "pollos-fritos-staging",
+				zone:      "antarctica-east1-b",
+			},
+			env: map[string]string{
+				"K_SERVICE":       "hal-api",
+				"K_REVISION":      "hal-api-00023-xyz",
+				"K_CONFIGURATION": "hal-api",
+			},
+			wantResourceType: "cloud_run_revision",
+			wantResourceLabels: map[string]string{
+				"project_id":         "pollos-fritos-staging",
+				"service_name":       "hal-api",
+				"revision_name":      "hal-api-00023-xyz",
+				"configuration_name": "hal-api",
+				"location":           "antarctica-east1-b",
+			},
+		},
+		{
+			description: "GAE services use gae_app",
+			metadata: &fakeGCEMetadata{
+				projectID: "pollos-fritos-staging",
+				zone:      "antarctica-east1-b",
+			},
+			env: map[string]string{
+				"GAE_SERVICE": "hal-frontend",
+				"GAE_VERSION": "20260101t120000",
+			},
+			wantResourceType: "gae_app",
+			wantResourceLabels: map[string]string{
+				"project_id": "pollos-fritos-staging",
+				"module_id":  "hal-frontend",
+				"version_id": "20260101t120000",
+				"zone":       "antarctica-east1-b",
+			},
+		},
+		{
+			description: "processes in a k8s pod without a container name use k8s_pod",
+			metadata: &fakeGCEMetadata{
+				projectID:   "pollos-fritos-prod",
+				zone:        "antarctica-east1-b",
+				clusterName: "secret-bioweapons-lab-prod-1",
+			},
+			env: map[string]string{
+				"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+				"POD_NAMESPACE":           "default",
+				"POD_NAME":                "hal-9000-abcde",
+			},
+			wantResourceType: "k8s_pod",
+			wantResourceLabels: map[string]string{
+				"project_id":     "pollos-fritos-prod",
+				"location":       "antarctica-east1-b",
+				"cluster_name":   "secret-bioweapons-lab-prod-1",
+				"namespace_name": "default",
+				"pod_name":       "hal-9000-abcde",
+			},
+		},
+		{
+			description: "processes in a k8s pod with a container name use k8s_container",
+			metadata: &fakeGCEMetadata{
+				projectID:   "pollos-fritos-prod",
+				zone:        "antarctica-east1-b",
+				clusterName: "secret-bioweapons-lab-prod-1",
+			},
+			env: map[string]string{
+				"KUBERNETES_SERVICE_HOST": "10.0.0.1",
+				"POD_NAMESPACE":           "default",
+				"POD_NAME":                "hal-9000-abcde",
+				"CONTAINER_NAME":          "hal",
+			},
+			wantResourceType: "k8s_container",
+			wantResourceLabels: map[string]string{
+				"project_id":     "pollos-fritos-prod",
+				"location":       "antarctica-east1-b",
+				"cluster_name":   "secret-bioweapons-lab-prod-1",
+				"namespace_name": "default",
+				"pod_name":       "hal-9000-abcde",
+				"container_name": "hal",
+			},
+		},
+		{
+			description:      "bare-metal/off-GCE nodes fall back to generic_node",
+			metadata:         &fakeGCEMetadata{},
+			wantResourceType: "generic_node",
+			wantResourceLabels: map[string]string{
+				"project_id": "",
+				"location":   "",
+				"node_id":    "",
+			},
+		},
 	} {
 		t.Run(tc.description, func(t *testing.T) {
+			// Clear every environment variable the resource detectors look
+			// at, then apply this test case's overrides, so cases can't
+			// leak state into each other.
+			for _, k := range []string{
+				"K_SERVICE", "K_REVISION", "K_CONFIGURATION",
+				"GAE_SERVICE", "GAE_VERSION",
+				"KUBERNETES_SERVICE_HOST", "POD_NAMESPACE", "POD_NAME", "CONTAINER_NAME",
+			} {
+				t.Setenv(k, "")
+			}
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
 			// The test cases must run sequentially because cloudLogger and cloudLoggingClient are shared module-level variables.
 			if err := Init(context.Background(), LogOpts{
 				LoggerName:          "guest-logging-go-end-to-end-test",
@@ -0,0 +1,129 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import "time"
+
+// Field is a typed key/value pair attached to a structured log entry,
+// constructed with String, Int, Err, Duration, Any, etc.
+type Field struct {
+	key   string
+	value interface{}
+}
+
+// String constructs a Field with a string value.
+func String(key, val string) Field {
+	return Field{key: key, value: val}
+}
+
+// Int constructs a Field with an int value.
+func Int(key string, val int) Field {
+	return Field{key: key, value: val}
+}
+
+// Int64 constructs a Field with an int64 value.
+func Int64(key string, val int64) Field {
+	return Field{key: key, value: val}
+}
+
+// Bool constructs a Field with a bool value.
+func Bool(key string, val bool) Field {
+	return Field{key: key, value: val}
+}
+
+// Duration constructs a Field whose value is val rendered as a duration string.
+func Duration(key string, val time.Duration) Field {
+	return Field{key: key, value: val.String()}
+}
+
+// Err constructs a Field named "error" from err. A nil err produces a Field
+// with an empty value so callers can unconditionally pass logger.Err(err).
+func Err(err error) Field {
+	if err == nil {
+		return Field{key: "error", value: ""}
+	}
+	return Field{key: "error", value: err.Error()}
+}
+
+// Any constructs a Field from an arbitrary value.
+func Any(key string, val interface{}) Field {
+	return Field{key: key, value: val}
+}
+
+// Logger is a structured logger that binds a fixed set of Fields to every
+// entry it emits, so callers don't have to thread common context (request
+// ID, subsystem name, ...) through every call.
+type Logger struct {
+	fields []Field
+}
+
+// With returns a Logger that attaches fields to every entry it logs, in
+// addition to any fields passed to the individual call.
+func With(fields ...Field) *Logger {
+	return &Logger{fields: fields}
+}
+
+// Std is a Logger with no bound fields, for structured logging calls that
+// don't need any (logger.Std.Info("message", logger.String("k", "v"))).
+// It's the top-level entry point for the common case: package-level
+// functions named Debug, Info, etc. aren't possible here since those names
+// are already the re-exported logging.Severity constants in logevent.go,
+// so Std.Info/Std.Debug/... stand in for them without requiring callers to
+// call With() themselves first.
+var Std = With()
+
+// payload merges the Logger's bound fields with per-call fields into a
+// StructuredPayload, or nil if there are none.
+func (l *Logger) payload(fields []Field) map[string]interface{} {
+	if len(l.fields) == 0 && len(fields) == 0 {
+		return nil
+	}
+	payload := make(map[string]interface{}, len(l.fields)+len(fields))
+	for _, f := range l.fields {
+		payload[f.key] = f.value
+	}
+	for _, f := range fields {
+		payload[f.key] = f.value
+	}
+	return payload
+}
+
+// Debug logs debug information with structured fields.
+func (l *Logger) Debug(msg string, fields ...Field) {
+	if !debugEnabled {
+		return
+	}
+	Log(LogEntry{Message: msg, Severity: Debug, StructuredPayload: l.payload(fields)})
+}
+
+// Info logs general information with structured fields.
+func (l *Logger) Info(msg string, fields ...Field) {
+	Log(LogEntry{Message: msg, Severity: Info, StructuredPayload: l.payload(fields)})
+}
+
+// Warning logs warning information with structured fields.
+func (l *Logger) Warning(msg string, fields ...Field) {
+	Log(LogEntry{Message: msg, Severity: Warning, StructuredPayload: l.payload(fields)})
+}
+
+// Error logs error information with structured fields.
+func (l *Logger) Error(msg string, fields ...Field) {
+	Log(LogEntry{Message: msg, Severity: Error, StructuredPayload: l.payload(fields)})
+}
+
+// Critical logs critical error information with structured fields.
+func (l *Logger) Critical(msg string, fields ...Field) {
+	Log(LogEntry{Message: msg, Severity: Critical, StructuredPayload: l.payload(fields)})
+}
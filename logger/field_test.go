@@ -0,0 +1,60 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import "testing"
+
+func TestLoggerWithMergesFields(t *testing.T) {
+	l := With(String("subsystem", "agent"), Int("attempt", 1))
+	got := l.payload([]Field{String("request_id", "abc"), Int("attempt", 2)})
+
+	want := map[string]interface{}{
+		"subsystem":  "agent",
+		"attempt":    2,
+		"request_id": "abc",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("payload() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("payload()[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestStdHasNoBoundFields(t *testing.T) {
+	got := Std.payload([]Field{String("request_id", "abc")})
+	want := map[string]interface{}{"request_id": "abc"}
+	if len(got) != len(want) || got["request_id"] != want["request_id"] {
+		t.Errorf("Std.payload() = %v, want %v", got, want)
+	}
+}
+
+func TestFormatStructuredPayloadIsSortedByKey(t *testing.T) {
+	got := formatStructuredPayload(map[string]interface{}{"b": 2, "a": 1})
+	if want := "a=1 b=2"; got != want {
+		t.Errorf("formatStructuredPayload() = %q, want %q", got, want)
+	}
+}
+
+func BenchmarkLoggerDebugDisabled(b *testing.B) {
+	debugEnabled = false
+	l := With(String("subsystem", "bench"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l.Debug("message", Int("i", i))
+	}
+}
@@ -0,0 +1,216 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"time"
+)
+
+// FluentForwardSink delivers entries to a local fluent-bit/fluentd agent
+// using the Fluent Forward protocol's Message Mode, i.e. a MessagePack
+// [tag, time, record] array per entry. It connects lazily and reconnects
+// on the next Write after any error, so a fluent-bit restart doesn't wedge
+// the sink.
+type FluentForwardSink struct {
+	// addr is the fluent-bit forward input address, e.g. "127.0.0.1:24224".
+	addr string
+	// tag is the Fluentd tag attached to every entry.
+	tag string
+
+	dialTimeout time.Duration
+	conn        net.Conn
+}
+
+// NewFluentForwardSink returns a FluentForwardSink that forwards entries to
+// addr under tag.
+func NewFluentForwardSink(addr, tag string) *FluentForwardSink {
+	return &FluentForwardSink{addr: addr, tag: tag, dialTimeout: 2 * time.Second}
+}
+
+// Write sends e to the forward input as a Message Mode [tag, time, record]
+// entry, dialing the connection first if one isn't already open.
+func (s *FluentForwardSink) Write(ctx context.Context, e LogEntry) error {
+	if s.conn == nil {
+		dialer := net.Dialer{Timeout: s.dialTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+		if err != nil {
+			return fmt.Errorf("fluent-forward sink: dial %s: %v", s.addr, err)
+		}
+		s.conn = conn
+	}
+
+	msg := msgpackArrayHeader(3)
+	msg = append(msg, msgpackString(s.tag)...)
+	msg = append(msg, msgpackInt(otelTimestamp(e)/int64(time.Second))...)
+	msg = append(msg, msgpackMap(fluentRecord(e))...)
+
+	// net.Conn has no context-aware Write, so sinkRunner's ctx (which
+	// carries a cancelation, not a deadline: see sink.go) is applied by
+	// forcing the conn's deadline the moment ctx is done, unblocking a
+	// write stuck on an unresponsive peer instead of letting it hold up
+	// sinkRunner.close indefinitely. conn is captured into a local so the
+	// watcher goroutine never touches s.conn, which the write-error path
+	// below reassigns without synchronization.
+	conn := s.conn
+	unblocked := make(chan struct{})
+	defer close(unblocked)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-unblocked:
+		}
+	}()
+
+	if _, err := conn.Write(msg); err != nil {
+		conn.Close()
+		s.conn = nil
+		return fmt.Errorf("fluent-forward sink: write: %v", err)
+	}
+	return nil
+}
+
+// Flush is a no-op: Write sends each entry as soon as it's received.
+func (s *FluentForwardSink) Flush() error { return nil }
+
+// Close closes the underlying connection, if one is open.
+func (s *FluentForwardSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// fluentRecord builds the Fluentd record for e: the message, severity,
+// source location, and any labels or structured fields.
+func fluentRecord(e LogEntry) map[string]interface{} {
+	record := map[string]interface{}{
+		"message":  e.Message,
+		"severity": e.Severity.String(),
+	}
+	for k, v := range e.StructuredPayload {
+		record[k] = v
+	}
+	if len(e.Labels) > 0 {
+		labels := make(map[string]interface{}, len(e.Labels))
+		for k, v := range e.Labels {
+			labels[k] = v
+		}
+		record["labels"] = labels
+	}
+	if e.Source != nil {
+		record["source"] = map[string]interface{}{
+			"file":     e.Source.File,
+			"line":     e.Source.Line,
+			"function": e.Source.Function,
+		}
+	}
+	return record
+}
+
+// The following encode the subset of MessagePack needed for a Fluent
+// Forward record: arrays, maps, strings, and integers. They favor the
+// simplest correct encoding (the widest applicable format) over the most
+// compact one.
+
+func msgpackArrayHeader(n int) []byte {
+	return []byte{0xdd, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+func msgpackMapHeader(n int) []byte {
+	return []byte{0xdf, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+func msgpackString(s string) []byte {
+	n := len(s)
+	buf := []byte{0xdb, byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+	return append(buf, []byte(s)...)
+}
+
+func msgpackInt(i int64) []byte {
+	buf := make([]byte, 9)
+	buf[0] = 0xd3
+	for shift := 0; shift < 8; shift++ {
+		buf[8-shift] = byte(i >> (8 * shift))
+	}
+	return buf
+}
+
+func msgpackBool(b bool) []byte {
+	if b {
+		return []byte{0xc3}
+	}
+	return []byte{0xc2}
+}
+
+func msgpackFloat(f float64) []byte {
+	bits := math.Float64bits(f)
+	buf := make([]byte, 9)
+	buf[0] = 0xcb
+	for shift := 0; shift < 8; shift++ {
+		buf[8-shift] = byte(bits >> (8 * shift))
+	}
+	return buf
+}
+
+// msgpackMap encodes m as a MessagePack map, with keys sorted for
+// deterministic output (useful for tests; Fluentd doesn't care about key
+// order).
+func msgpackMap(m map[string]interface{}) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.Write(msgpackMapHeader(len(keys)))
+	for _, k := range keys {
+		buf.Write(msgpackString(k))
+		buf.Write(msgpackValue(m[k]))
+	}
+	return buf.Bytes()
+}
+
+// msgpackValue encodes a single record value: the record types we produce
+// are strings, integers, floats, bools, and nested string maps.
+func msgpackValue(v interface{}) []byte {
+	switch val := v.(type) {
+	case string:
+		return msgpackString(val)
+	case bool:
+		return msgpackBool(val)
+	case int:
+		return msgpackInt(int64(val))
+	case int64:
+		return msgpackInt(val)
+	case float32:
+		return msgpackFloat(float64(val))
+	case float64:
+		return msgpackFloat(val)
+	case map[string]interface{}:
+		return msgpackMap(val)
+	default:
+		return msgpackString(fmt.Sprintf("%v", val))
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -27,38 +28,92 @@ import (
 	logpb "google.golang.org/genproto/googleapis/logging/v2"
 )
 
+// The following re-export the logging package's severities so callers can
+// write logger.Debug, logger.Info, etc. without importing cloud.google.com/go/logging.
+//
+// Because these names are already taken at package scope, the structured
+// logging API (see field.go) can't also offer package-level functions
+// logger.Debug(msg, fields...), logger.Info(msg, fields...), etc. — that
+// would redeclare Debug, Info, Warning, Error, and Critical as both a
+// constant and a func. Structured logging is exposed as *Logger methods
+// instead, reached either via With(...) or the ready-made Std logger.
+const (
+	Debug    = logging.Debug
+	Info     = logging.Info
+	Warning  = logging.Warning
+	Error    = logging.Error
+	Critical = logging.Critical
+)
+
 // LogEntry encapsulates a single log entry.
 type LogEntry struct {
 	Message   string            `json:"message"`
 	Labels    map[string]string `json:"-"`
 	CallDepth int               `json:"-"`
 	Severity  logging.Severity  `json:"-"`
-}
 
-type logEntry struct {
-	LogEntry
+	// StructuredPayload carries the typed fields attached via Field
+	// constructors (see Logger.Info, Logger.Error, With). When set, it is
+	// sent to Cloud Logging as the jsonPayload, with Message added under
+	// "message", and rendered as "key=value" pairs appended to the text
+	// line on the local sinks.
+	StructuredPayload map[string]interface{} `json:"-"`
+
+	// LocalTimestamp and Source are populated by Log before the entry
+	// reaches any sink.
+	LocalTimestamp string                        `json:"localTimestamp"`
+	Source         *logpb.LogEntrySourceLocation `json:"-"`
 
-	// annotate message and localTimestamp for payload use.
-	localTimestamp string `json:"localTimestamp"`
-	source         *logpb.LogEntrySourceLocation
+	// bypassSampler marks entries synthesized by the sampler itself (e.g.
+	// its suppressed-occurrences summaries), which must always be emitted
+	// rather than being fed back into the sampler.
+	bypassSampler bool
 }
 
-func (e logEntry) String() string {
+func (e LogEntry) String() string {
+	msg := e.Message
+	if len(e.StructuredPayload) > 0 {
+		msg = msg + " " + formatStructuredPayload(e.StructuredPayload)
+	}
 	if e.Severity == logging.Error || e.Severity == logging.Critical {
 		// 2006-01-02T15:04:05.999999Z07:00 ERROR file.go:82: This is a log message.
-		return fmt.Sprintf("%s %s %s:%d: %s", e.localTimestamp, e.Severity, e.source.File, e.source.Line, e.Message)
+		return fmt.Sprintf("%s %s %s:%d: %s", e.LocalTimestamp, e.Severity, e.Source.File, e.Source.Line, msg)
 	}
 	// 2006-01-02T15:04:05.999999Z07:00 INFO: This is a log message.
-	return fmt.Sprintf("%s %s: %s", e.localTimestamp, e.Severity, e.Message)
+	return fmt.Sprintf("%s %s: %s", e.LocalTimestamp, e.Severity, msg)
 }
 
-func (e logEntry) Bytes() []byte {
+// bytes renders e as the line written to the local sinks, preferring the
+// user-supplied FormatFunction when one is configured.
+func (e LogEntry) bytes() []byte {
+	if formatFunction != nil {
+		return []byte(strings.TrimSpace(formatFunction(e)) + "\n")
+	}
 	return []byte(strings.TrimSpace(e.String()) + "\n")
 }
 
+// formatStructuredPayload renders a structured payload as logfmt-style
+// "key=value" pairs, sorted by key for stable output.
+func formatStructuredPayload(payload map[string]interface{}) string {
+	keys := make([]string, 0, len(payload))
+	for k := range payload {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%v", k, payload[k]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// logTimestampLayout is the RFC3339-with-microseconds layout used for
+// LogEntry.LocalTimestamp, shared with sinks (see otel.go) that need to
+// parse it back into a time.Time.
+const logTimestampLayout = "2006-01-02T15:04:05.999999Z07:00"
+
 func now() string {
-	// RFC3339 with microseconds.
-	return time.Now().Format("2006-01-02T15:04:05.999999Z07:00")
+	return time.Now().Format(logTimestampLayout)
 }
 
 func caller(depth int) *logpb.LogEntrySourceLocation {
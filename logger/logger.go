@@ -23,9 +23,9 @@ import (
 	"regexp"
 	"time"
 
-	"cloud.google.com/go/compute/metadata"
 	"cloud.google.com/go/logging"
 	"google.golang.org/api/option"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
 )
 
 var (
@@ -37,10 +37,15 @@ var (
 	debugEnabled       bool
 	loggerName         string
 	formatFunction     func(LogEntry) string
+	activeSampler      *sampler
 
-	writers []io.Writer
+	sinkRunners []*sinkRunner
 )
 
+// closeDrainTimeout bounds how long Close waits for each sink to drain its
+// backlog, matching the timeout already used to Ping Cloud Logging.
+const closeDrainTimeout = 3 * time.Second
+
 const (
 	// The following are MIG labels.
 	migNameLabel   = `compute.googleapis.com/instance_group_manager/name`
@@ -62,6 +67,37 @@ type LogOpts struct {
 	UserAgent string
 	// MIG is the Managed Instance Group, used for labeling logs.
 	MIG string
+	// MetadataTimeout bounds each individual GCE/GKE metadata attribute
+	// lookup performed during Init (not the OnGCE check). Defaults to 2
+	// seconds.
+	MetadataTimeout time.Duration
+	// ResourceDetectors overrides the priority-ordered list of detectors
+	// used to pick the Cloud Logging monitored resource for the current
+	// environment. Defaults to defaultResourceDetectors.
+	ResourceDetectors []ResourceDetector
+	// SampleRate randomly drops a fraction of entries per severity (0..1,
+	// where 1 means keep everything). Severities not present in the map
+	// are never sampled. Error and Critical are never dropped regardless
+	// of SampleRate or BurstPerSecond.
+	SampleRate map[logging.Severity]float64
+	// BurstPerSecond bounds the sustained rate of entries per severity via
+	// a token bucket: each Log call consumes one token, refilled at this
+	// rate. Severities not present in the map are unbounded.
+	BurstPerSecond map[logging.Severity]int
+	// DuplicateThreshold is how many times an identical (severity, source,
+	// message) entry may be logged within DuplicateWindow before further
+	// occurrences are collapsed into a single periodic summary entry. Zero
+	// disables duplicate suppression.
+	DuplicateThreshold int
+	// DuplicateWindow is the rolling window DuplicateThreshold applies to.
+	DuplicateWindow time.Duration
+	// Sinks are additional destinations for log entries, such as an
+	// OpenTelemetry Logs sink or a Fluent Forward sink. They run
+	// alongside the built-in local, Writers, and Cloud Logging sinks.
+	Sinks []Sink
+
+	// metadata overrides the metadataProvider used during Init, for tests.
+	metadata metadataProvider
 }
 
 // SetDebugLogging enables or disables debug level logging.
@@ -104,12 +140,30 @@ func Init(ctx context.Context, opts LogOpts) error {
 	loggerName = opts.LoggerName
 	debugEnabled = opts.Debug
 	formatFunction = opts.FormatFunction
-	writers = opts.Writers
+	activeSampler = newSampler(opts.SampleRate, opts.BurstPerSecond, opts.DuplicateThreshold, opts.DuplicateWindow)
+	sinkRunners = nil
+
+	// Install a fresh metadata provider so that re-running Init always
+	// triggers a new probe instead of reusing a stale cache.
+	metadata := opts.metadata
+	if metadata == nil {
+		defaultGCEMetadataProvider = newGCEMetadataProvider(opts.MetadataTimeout)
+		metadata = defaultGCEMetadataProvider
+	}
 
 	if !opts.DisableLocalLogging {
 		if err := localSetup(loggerName); err != nil {
 			return fmt.Errorf("logger Init localSetup error: %v", err)
 		}
+		sinkRunners = append(sinkRunners, newSinkRunner("local", localSink{}))
+	}
+
+	for i, w := range opts.Writers {
+		sinkRunners = append(sinkRunners, newSinkRunner(fmt.Sprintf("writer-%d", i), writerSink{w: w}))
+	}
+
+	for i, s := range opts.Sinks {
+		sinkRunners = append(sinkRunners, newSinkRunner(fmt.Sprintf("custom-%d", i), s))
 	}
 
 	if !opts.DisableCloudLogging && opts.ProjectName != "" {
@@ -128,13 +182,17 @@ func Init(ctx context.Context, opts LogOpts) error {
 		// Override default error handler. Must be a func and not nil.
 		cloudLoggingClient.OnError = func(e error) { return }
 
-		// The logger automatically detects and associates with a GCE
-		// resource. However instance_name is not included in this
-		// resource, so add an instance_name label to all log Entries.
-		name, err := metadata.InstanceName()
+		// Pick the Cloud Logging monitored resource for the environment
+		// we're running in, and any labels that go with it.
+		detectors := opts.ResourceDetectors
+		if len(detectors) == 0 {
+			detectors = defaultResourceDetectors
+		}
+		resourceType, resourceLabels, entryLabels := detectResource(detectors, metadata)
+
 		labels := make(map[string]string)
-		if err == nil {
-			labels["instance_name"] = name
+		for k, v := range entryLabels {
+			labels[k] = v
 		}
 
 		// Add MIG labels if provided.
@@ -144,12 +202,15 @@ func Init(ctx context.Context, opts LogOpts) error {
 		}
 
 		// Initialize the logger.
-		cloudLogger = cloudLoggingClient.Logger(loggerName, logging.CommonLabels(labels))
+		resource := &mrpb.MonitoredResource{Type: resourceType, Labels: resourceLabels}
+		cloudLogger = cloudLoggingClient.Logger(loggerName, logging.CommonLabels(labels), logging.CommonResource(resource))
+		cloudRunner := newSinkRunner("cloud", cloudSink{client: cloudLoggingClient, logger: cloudLogger})
+		sinkRunners = append(sinkRunners, cloudRunner)
 
 		go func() {
 			for {
 				time.Sleep(5 * time.Second)
-				cloudLogger.Flush()
+				cloudRunner.sink.Flush()
 			}
 		}()
 	}
@@ -157,21 +218,32 @@ func Init(ctx context.Context, opts LogOpts) error {
 	return nil
 }
 
-// Close closes the logger.
-func Close() {
+// Close closes the logger, draining and closing every sink.
+func Close() error {
+	skipCloud := false
 	if cloudLoggingClient != nil {
-		// Attempt to connect to Cloud Logging.
-		timeoutContext, cancelFunc := context.WithTimeout(context.Background(), time.Second*3)
+		// Attempt to connect to Cloud Logging before draining its sink, so a
+		// permanently unreachable endpoint doesn't eat the whole drain
+		// deadline.
+		timeoutContext, cancelFunc := context.WithTimeout(context.Background(), closeDrainTimeout)
 		defer cancelFunc()
 
 		if err := cloudLoggingClient.Ping(timeoutContext); err != nil {
 			Warningf("Cannot connect to cloud logging, skipping flush: %v", err)
-		} else {
-			cloudLogger.Flush()
-			cloudLoggingClient.Close()
+			skipCloud = true
+		}
+	}
+
+	deadline := time.Now().Add(closeDrainTimeout)
+	var firstErr error
+	for _, r := range sinkRunners {
+		flush := !(skipCloud && r.name == "cloud")
+		if err := r.close(deadline, flush); err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
-	localClose()
+	sinkRunners = nil
+	return firstErr
 }
 
 // Log writes an entry to all outputs.
@@ -184,34 +256,24 @@ func Log(e LogEntry) {
 	}
 	e.LocalTimestamp = now()
 	e.Source = caller(e.CallDepth)
-	local(e)
-	for _, w := range writers {
-		w.Write(e.bytes())
-	}
-
-	var cloudSev logging.Severity
-	if cloudLogger != nil {
-		var payload interface{}
-		if e.StructuredPayload != nil {
-			payload = e.StructuredPayload
-		} else {
-			payload = e
+
+	if !e.bypassSampler && activeSampler != nil {
+		emit, summary := activeSampler.allow(e)
+		if !emit {
+			return
 		}
-		switch e.Severity {
-		case Debug:
-			cloudSev = logging.Debug
-		case Info:
-			cloudSev = logging.Info
-		case Warning:
-			cloudSev = logging.Warning
-		case Error:
-			cloudSev = logging.Error
-		case Critical:
-			cloudSev = logging.Critical
-		default:
-			cloudSev = logging.Default
+		if summary != nil {
+			// Log(*summary) below adds one more frame between here and
+			// caller() than the original e.CallDepth accounted for, so
+			// without the +1 the summary's Source resolves to this Log
+			// call instead of the suppressed entry's actual call site.
+			summary.CallDepth = e.CallDepth + 1
+			Log(*summary)
 		}
-		cloudLogger.Log(logging.Entry{Severity: cloudSev, SourceLocation: e.Source, Payload: payload, Labels: e.Labels})
+	}
+
+	for _, r := range sinkRunners {
+		r.write(e)
 	}
 }
 
@@ -0,0 +1,76 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// sourceRecordingSink records every entry's Source, for asserting which
+// call site an entry is attributed to.
+type sourceRecordingSink struct {
+	entries []LogEntry
+}
+
+func (s *sourceRecordingSink) Write(ctx context.Context, e LogEntry) error {
+	s.entries = append(s.entries, e)
+	return nil
+}
+func (*sourceRecordingSink) Flush() error { return nil }
+func (*sourceRecordingSink) Close() error { return nil }
+
+// logAtTestCallSite calls Log directly (CallDepth: 1, i.e. no Infof-style
+// wrapper in between), so every call below resolves to this same line.
+func logAtTestCallSite(msg string) {
+	Log(LogEntry{Message: msg, Severity: Info, CallDepth: 1})
+}
+
+func TestLogSuppressedSummaryAttributesToSuppressedCallSite(t *testing.T) {
+	activeSampler = newSampler(nil, nil, 1, 20*time.Millisecond)
+	defer func() { activeSampler = nil }()
+
+	sink := &sourceRecordingSink{}
+	r := newSinkRunner("recording", sink)
+	sinkRunners = []*sinkRunner{r}
+	defer func() { sinkRunners = nil }()
+
+	for i := 0; i < 3; i++ {
+		logAtTestCallSite("retrying")
+	}
+	time.Sleep(30 * time.Millisecond)
+	logAtTestCallSite("retrying")
+
+	if err := r.close(time.Now().Add(time.Second), false); err != nil {
+		t.Fatalf("close() = %v, want nil", err)
+	}
+
+	var wantLine int64
+	var sawSummary bool
+	for _, e := range sink.entries {
+		if e.Labels["suppressed_count"] != "" {
+			sawSummary = true
+			if e.Source.Line != wantLine {
+				t.Errorf("summary Source.Line = %d, want %d (the suppressed call site, not Log's)", e.Source.Line, wantLine)
+			}
+			continue
+		}
+		wantLine = e.Source.Line
+	}
+	if !sawSummary {
+		t.Fatalf("no suppressed-occurrences summary entry was emitted")
+	}
+}
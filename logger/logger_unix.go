@@ -38,7 +38,7 @@ func localClose() {
 	slWriter.Close()
 }
 
-func local(e logEntry) {
+func local(e LogEntry) {
 	msg := e.String()
 	switch e.Severity {
 	case logging.Debug:
@@ -58,6 +58,6 @@ func local(e logEntry) {
 		if (e.Severity == logging.Debug) && !debugEnabled {
 			return
 		}
-		os.Stdout.Write(e.Bytes())
+		os.Stdout.Write(e.bytes())
 	}
 }
@@ -15,9 +15,19 @@
 package logger
 
 import (
+	"net/http"
+	"sync"
+	"time"
+
 	"cloud.google.com/go/compute/metadata"
 )
 
+// defaultMetadataTimeout bounds each individual metadata server lookup
+// performed by gceMetadataProvider when LogOpts.MetadataTimeout isn't set.
+// GKE Sandbox nodes and some GKE Metadata Server proxy configurations can
+// accept a connection and never respond, so every lookup must be bounded.
+const defaultMetadataTimeout = 2 * time.Second
+
 type metadataProvider interface {
 	K8sClusterName() string
 	InstanceName() string
@@ -28,16 +38,83 @@ type metadataProvider interface {
 	OnGKE() bool
 }
 
-type gceMetadataProvider struct{}
+// gceMetadataProvider implements metadataProvider against the real GCE/GKE
+// metadata server, with every attribute lookup bounded by timeout via the
+// client's underlying http.Client; the OnGCE check uses the metadata
+// package's own detection and is not bounded by timeout. Results are
+// probed once and cached; callers that
+// need a fresh probe (e.g. after Init is called again) get a new provider.
+type gceMetadataProvider struct {
+	client *metadata.Client
 
-var defaultGCEMetadataProvider *gceMetadataProvider = &gceMetadataProvider{}
+	once         sync.Once
+	onGCE        bool
+	instanceName string
+	instanceID   string
+	zone         string
+	projectID    string
+	clusterName  string
+}
 
-func (m *gceMetadataProvider) K8sClusterName() string {
-	cluster, err := metadata.InstanceAttributeValue("cluster-name")
-	if err != nil {
-		return ""
+var defaultGCEMetadataProvider = newGCEMetadataProvider(defaultMetadataTimeout)
+
+// newGCEMetadataProvider returns a gceMetadataProvider whose lookups are
+// each bounded by timeout.
+func newGCEMetadataProvider(timeout time.Duration) *gceMetadataProvider {
+	if timeout <= 0 {
+		timeout = defaultMetadataTimeout
 	}
-	return cluster
+	return &gceMetadataProvider{
+		client: metadata.NewClient(&http.Client{Timeout: timeout}),
+	}
+}
+
+// probe populates the cached metadata attributes, logging a Debug entry for
+// any attribute that times out or otherwise fails rather than returning an
+// error to the caller. It only ever runs once per provider instance; Init
+// installs a new provider to force a fresh probe.
+func (m *gceMetadataProvider) probe() {
+	m.once.Do(func() {
+		m.onGCE = metadata.OnGCE()
+		if !m.onGCE {
+			return
+		}
+
+		if name, err := m.client.InstanceName(); err != nil {
+			Debugf("metadata: failed to look up instance name: %v", err)
+		} else {
+			m.instanceName = name
+		}
+
+		if id, err := m.client.InstanceID(); err != nil {
+			Debugf("metadata: failed to look up instance ID: %v", err)
+		} else {
+			m.instanceID = id
+		}
+
+		if zone, err := m.client.Zone(); err != nil {
+			Debugf("metadata: failed to look up zone: %v", err)
+		} else {
+			m.zone = zone
+		}
+
+		if project, err := m.client.ProjectID(); err != nil {
+			Debugf("metadata: failed to look up project ID: %v", err)
+		} else {
+			m.projectID = project
+		}
+
+		if cluster, err := m.client.InstanceAttributeValue("cluster-name"); err != nil {
+			Debugf("metadata: failed to look up cluster-name attribute: %v", err)
+		} else {
+			m.clusterName = cluster
+		}
+	})
+}
+
+func (m *gceMetadataProvider) K8sClusterName() string {
+	m.probe()
+	return m.clusterName
 }
 
 func (m *gceMetadataProvider) OnGKE() bool {
@@ -45,37 +122,26 @@ func (m *gceMetadataProvider) OnGKE() bool {
 }
 
 func (m *gceMetadataProvider) OnGCE() bool {
-	return metadata.OnGCE()
+	m.probe()
+	return m.onGCE
 }
 
 func (m *gceMetadataProvider) InstanceName() string {
-	name, err := metadata.InstanceName()
-	if err != nil {
-		return ""
-	}
-	return name
+	m.probe()
+	return m.instanceName
 }
 
 func (m *gceMetadataProvider) InstanceID() string {
-	id, err := metadata.InstanceID()
-	if err != nil {
-		return ""
-	}
-	return id
+	m.probe()
+	return m.instanceID
 }
 
 func (m *gceMetadataProvider) Zone() string {
-	zone, err := metadata.Zone()
-	if err != nil {
-		return ""
-	}
-	return zone
+	m.probe()
+	return m.zone
 }
 
 func (m *gceMetadataProvider) ProjectID() string {
-	project, err := metadata.ProjectID()
-	if err != nil {
-		return ""
-	}
-	return project
+	m.probe()
+	return m.projectID
 }
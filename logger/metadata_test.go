@@ -0,0 +1,79 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// hangingMetadataServer starts a listener that accepts every connection but
+// never writes a response, to simulate a GKE Sandbox/Metadata Server edge
+// case where OnGCE() succeeds but subsequent lookups hang forever. Accepted
+// connections are deliberately leaked (never read from or written to) for
+// the lifetime of the test binary; closing the listener only stops new
+// connections from being accepted.
+func hangingMetadataServer(t *testing.T) net.Addr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake metadata listener: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn // accepted and never touched again
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return ln.Addr()
+}
+
+func TestGCEMetadataProviderTimesOutOnHangingServer(t *testing.T) {
+	addr := hangingMetadataServer(t)
+
+	host := addr.String()
+	oldHost := os.Getenv("GCE_METADATA_HOST")
+	os.Setenv("GCE_METADATA_HOST", host)
+	defer os.Setenv("GCE_METADATA_HOST", oldHost)
+
+	timeout := 200 * time.Millisecond
+	p := newGCEMetadataProvider(timeout)
+
+	start := time.Now()
+	name := p.InstanceName()
+	elapsed := time.Since(start)
+
+	// probe() makes up to 5 sequential lookups, each bounded by timeout via
+	// the client's http.Client.Timeout, so the whole probe should finish
+	// within a small multiple of that (with a margin for scheduling).
+	if max := timeout * 10; elapsed > max {
+		t.Errorf("InstanceName took %v, want less than %v", elapsed, max)
+	}
+	if name != "" {
+		t.Errorf("InstanceName() = %q, want empty string since the lookup never succeeded", name)
+	}
+
+	// Every lookup against the hanging server fails, so Zone() is empty as
+	// well; it returns the cached probe result instead of probing again.
+	if zone := p.Zone(); zone != "" {
+		t.Errorf("Zone() = %q, want empty string", zone)
+	}
+}
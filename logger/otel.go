@@ -0,0 +1,187 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// OTelSink delivers entries to an OpenTelemetry Logs collector over OTLP/HTTP,
+// so a guest agent can ship the same entries to a collector that also
+// receives its traces. It does not depend on the OpenTelemetry SDK: it
+// encodes the OTLP JSON wire format directly, keeping this package's
+// dependency footprint unchanged.
+type OTelSink struct {
+	// endpoint is the collector's logs endpoint, e.g.
+	// "http://localhost:4318/v1/logs".
+	endpoint string
+	// resourceAttrs are attached to every exported LogRecord's Resource,
+	// such as service.name or host.id.
+	resourceAttrs map[string]string
+	client        *http.Client
+}
+
+// NewOTelSink returns an OTelSink that POSTs each entry to endpoint as a
+// single-entry OTLP/HTTP ExportLogsServiceRequest. resourceAttrs is attached
+// to every exported record's Resource and may be nil.
+func NewOTelSink(endpoint string, resourceAttrs map[string]string) *OTelSink {
+	return &OTelSink{
+		endpoint:      endpoint,
+		resourceAttrs: resourceAttrs,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Write exports e to the collector as a single-entry OTLP/HTTP request.
+func (s *OTelSink) Write(ctx context.Context, e LogEntry) error {
+	body, err := json.Marshal(s.exportRequest(e))
+	if err != nil {
+		return fmt.Errorf("otel sink: marshal export request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("otel sink: build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otel sink: export: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("otel sink: collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Flush is a no-op: each entry is exported synchronously by Write.
+func (s *OTelSink) Flush() error { return nil }
+
+// Close releases the sink's idle HTTP connections.
+func (s *OTelSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// exportRequest builds the OTLP/HTTP JSON ExportLogsServiceRequest for a
+// single entry. See
+// https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/logs/v1/logs.proto
+// for the wire format this mirrors.
+func (s *OTelSink) exportRequest(e LogEntry) map[string]interface{} {
+	attrs := make([]map[string]interface{}, 0, len(e.Labels)+len(e.StructuredPayload))
+	for k, v := range e.Labels {
+		attrs = append(attrs, otelKeyValue(k, v))
+	}
+	for k, v := range e.StructuredPayload {
+		attrs = append(attrs, otelKeyValue(k, v))
+	}
+	if e.Source != nil {
+		attrs = append(attrs, otelKeyValue("code.filepath", e.Source.File))
+		attrs = append(attrs, otelKeyValue("code.function", e.Source.Function))
+		attrs = append(attrs, otelKeyValue("code.lineno", e.Source.Line))
+	}
+
+	record := map[string]interface{}{
+		"timeUnixNano":         strconv.FormatInt(otelTimestamp(e), 10),
+		"severityNumber":       otelSeverityNumber(e.Severity),
+		"severityText":         e.Severity.String(),
+		"body":                 otelAnyValue(e.Message),
+		"attributes":           attrs,
+		"observedTimeUnixNano": strconv.FormatInt(otelTimestamp(e), 10),
+	}
+
+	resourceAttrs := make([]map[string]interface{}, 0, len(s.resourceAttrs))
+	for k, v := range s.resourceAttrs {
+		resourceAttrs = append(resourceAttrs, otelKeyValue(k, v))
+	}
+
+	return map[string]interface{}{
+		"resourceLogs": []map[string]interface{}{
+			{
+				"resource": map[string]interface{}{
+					"attributes": resourceAttrs,
+				},
+				"scopeLogs": []map[string]interface{}{
+					{
+						"scope":      map[string]interface{}{"name": "guest-logging-go"},
+						"logRecords": []map[string]interface{}{record},
+					},
+				},
+			},
+		},
+	}
+}
+
+// otelTimestamp returns e's LocalTimestamp as Unix nanoseconds, falling
+// back to the current time if it can't be parsed.
+func otelTimestamp(e LogEntry) int64 {
+	t, err := time.Parse(logTimestampLayout, e.LocalTimestamp)
+	if err != nil {
+		t = time.Now()
+	}
+	return t.UnixNano()
+}
+
+// otelSeverityNumber maps a Cloud Logging severity onto the OTLP
+// SeverityNumber enum (see logs.proto), whose values are part of the wire
+// format and so are reproduced here as integers rather than constants.
+func otelSeverityNumber(sev logging.Severity) int {
+	switch sev {
+	case Debug:
+		return 5 // SEVERITY_NUMBER_DEBUG
+	case Info:
+		return 9 // SEVERITY_NUMBER_INFO
+	case Warning:
+		return 13 // SEVERITY_NUMBER_WARN
+	case Error:
+		return 17 // SEVERITY_NUMBER_ERROR
+	case Critical:
+		return 21 // SEVERITY_NUMBER_FATAL
+	default:
+		return 0 // SEVERITY_NUMBER_UNSPECIFIED
+	}
+}
+
+// otelKeyValue builds an OTLP KeyValue from a Go value.
+func otelKeyValue(key string, val interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   key,
+		"value": otelAnyValue(val),
+	}
+}
+
+// otelAnyValue wraps val in an OTLP AnyValue oneof.
+func otelAnyValue(val interface{}) map[string]interface{} {
+	switch v := val.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": v}
+	case int, int32, int64:
+		return map[string]interface{}{"intValue": fmt.Sprintf("%d", v)}
+	case float32, float64:
+		return map[string]interface{}{"doubleValue": v}
+	default:
+		return map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+	}
+}
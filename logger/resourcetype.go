@@ -0,0 +1,140 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import "os"
+
+// ResourceDetector inspects the current environment and, if it recognizes
+// it, returns the Cloud Logging monitored resource type and labels to
+// report for it, along with any labels that should be attached to every
+// LogEntry. Detectors are tried in order; the first one that returns
+// ok=true wins.
+type ResourceDetector func(m metadataProvider) (resourceType string, resourceLabels map[string]string, entryLabels map[string]string, ok bool)
+
+// defaultResourceDetectors is the priority-ordered list of detectors Init
+// runs unless LogOpts.ResourceDetectors is set. Detectors earlier in the
+// list take priority when more than one could apply.
+var defaultResourceDetectors = []ResourceDetector{
+	detectCloudRun,
+	detectGAE,
+	detectK8sPod,
+	detectGKENode,
+	detectGCEInstance,
+	detectGenericNode,
+}
+
+// detectCloudRun recognizes a Cloud Run revision via its well-known
+// environment variables.
+func detectCloudRun(m metadataProvider) (string, map[string]string, map[string]string, bool) {
+	service := os.Getenv("K_SERVICE")
+	revision := os.Getenv("K_REVISION")
+	if service == "" || revision == "" {
+		return "", nil, nil, false
+	}
+	return "cloud_run_revision", map[string]string{
+		"project_id":         m.ProjectID(),
+		"service_name":       service,
+		"revision_name":      revision,
+		"configuration_name": os.Getenv("K_CONFIGURATION"),
+		"location":           m.Zone(),
+	}, nil, true
+}
+
+// detectGAE recognizes a Google App Engine service via its well-known
+// environment variables.
+func detectGAE(m metadataProvider) (string, map[string]string, map[string]string, bool) {
+	service := os.Getenv("GAE_SERVICE")
+	version := os.Getenv("GAE_VERSION")
+	if service == "" || version == "" {
+		return "", nil, nil, false
+	}
+	return "gae_app", map[string]string{
+		"project_id": m.ProjectID(),
+		"module_id":  service,
+		"version_id": version,
+		"zone":       m.Zone(),
+	}, nil, true
+}
+
+// detectK8sPod recognizes a process running inside a Kubernetes pod, using
+// KUBERNETES_SERVICE_HOST (set by the kubelet in every pod) together with
+// namespace/pod name supplied through the downward API.
+func detectK8sPod(m metadataProvider) (string, map[string]string, map[string]string, bool) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return "", nil, nil, false
+	}
+	labels := map[string]string{
+		"project_id":     m.ProjectID(),
+		"location":       m.Zone(),
+		"cluster_name":   m.K8sClusterName(),
+		"namespace_name": os.Getenv("POD_NAMESPACE"),
+		"pod_name":       os.Getenv("POD_NAME"),
+	}
+	if container := os.Getenv("CONTAINER_NAME"); container != "" {
+		labels["container_name"] = container
+		return "k8s_container", labels, nil, true
+	}
+	return "k8s_pod", labels, nil, true
+}
+
+// detectGKENode recognizes a GCE instance that is a GKE node (but not
+// running inside a pod - that's detectK8sPod's job).
+func detectGKENode(m metadataProvider) (string, map[string]string, map[string]string, bool) {
+	if !m.OnGCE() || !m.OnGKE() {
+		return "", nil, nil, false
+	}
+	return "k8s_node", map[string]string{
+		"node_name":    m.InstanceName(),
+		"project_id":   m.ProjectID(),
+		"location":     m.Zone(),
+		"cluster_name": m.K8sClusterName(),
+	}, nil, true
+}
+
+// detectGCEInstance recognizes a plain GCE instance.
+func detectGCEInstance(m metadataProvider) (string, map[string]string, map[string]string, bool) {
+	if !m.OnGCE() {
+		return "", nil, nil, false
+	}
+	return "gce_instance", map[string]string{
+			"instance_id": m.InstanceID(),
+			"project_id":  m.ProjectID(),
+			"zone":        m.Zone(),
+		}, map[string]string{
+			// instance_name isn't part of the gce_instance resource, so it's
+			// carried as an entry label instead.
+			"instance_name": m.InstanceName(),
+		}, true
+}
+
+// detectGenericNode is the fallback used when nothing more specific is
+// recognized, e.g. on bare-metal or another cloud provider.
+func detectGenericNode(m metadataProvider) (string, map[string]string, map[string]string, bool) {
+	return "generic_node", map[string]string{
+		"project_id": m.ProjectID(),
+		"location":   m.Zone(),
+		"node_id":    m.InstanceID(),
+	}, nil, true
+}
+
+// detectResource runs detectors in order and returns the first match.
+func detectResource(detectors []ResourceDetector, m metadataProvider) (resourceType string, resourceLabels, entryLabels map[string]string) {
+	for _, d := range detectors {
+		if t, rl, el, ok := d(m); ok {
+			return t, rl, el
+		}
+	}
+	return "global", nil, nil
+}
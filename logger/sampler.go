@@ -0,0 +1,239 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// SeverityStats reports how many entries of a given severity have been
+// emitted, dropped by sampling, or collapsed as duplicates.
+type SeverityStats struct {
+	Emitted    int64
+	Sampled    int64
+	Suppressed int64
+}
+
+// tokenBucket is a per-severity rate limiter: it holds at most burst
+// tokens, refilling at burst-per-second, allowing bursts while bounding
+// the sustained rate.
+type tokenBucket struct {
+	mu       sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+}
+
+func newTokenBucket(burstPerSecond int) *tokenBucket {
+	if burstPerSecond <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		rate:     float64(burstPerSecond),
+		burst:    float64(burstPerSecond),
+		tokens:   float64(burstPerSecond),
+		lastFill: time.Now(),
+	}
+}
+
+func (b *tokenBucket) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// dupKey identifies entries that should be deduplicated: same severity,
+// same call site, same message.
+type dupKey struct {
+	severity logging.Severity
+	file     string
+	line     int64
+	message  string
+}
+
+type dupWindow struct {
+	start time.Time
+	count int
+}
+
+// sampler bounds the volume of entries Log actually emits: a per-severity
+// sample rate and token bucket, plus duplicate-entry suppression within a
+// rolling window. Error and Critical entries are never dropped or
+// suppressed, only counted.
+type sampler struct {
+	rates        map[logging.Severity]float64
+	buckets      map[logging.Severity]*tokenBucket
+	dupThreshold int
+	dupWindow    time.Duration
+
+	mu        sync.Mutex
+	dups      map[dupKey]*dupWindow
+	nextSweep time.Time
+	stats     map[logging.Severity]*SeverityStats
+}
+
+// newSampler builds a sampler from the given LogOpts. It always returns a
+// non-nil sampler so Log can call into it unconditionally; with no opts
+// set, every entry is allowed through.
+func newSampler(rates map[logging.Severity]float64, burstPerSecond map[logging.Severity]int, dupThreshold int, dupWindowDuration time.Duration) *sampler {
+	buckets := make(map[logging.Severity]*tokenBucket, len(burstPerSecond))
+	for sev, burst := range burstPerSecond {
+		buckets[sev] = newTokenBucket(burst)
+	}
+	return &sampler{
+		rates:        rates,
+		buckets:      buckets,
+		dupThreshold: dupThreshold,
+		dupWindow:    dupWindowDuration,
+		dups:         make(map[dupKey]*dupWindow),
+		stats:        make(map[logging.Severity]*SeverityStats),
+	}
+}
+
+func (s *sampler) statsFor(sev logging.Severity) *SeverityStats {
+	st, ok := s.stats[sev]
+	if !ok {
+		st = &SeverityStats{}
+		s.stats[sev] = st
+	}
+	return st
+}
+
+// allow decides whether e should be emitted. It returns emit=false when e
+// should be dropped. When e is the first occurrence of a new duplicate
+// window and the previous window suppressed occurrences, it also returns a
+// summary LogEntry reporting how many, to emit alongside e (emit=true).
+// Error and Critical severities always return emit=true.
+func (s *sampler) allow(e LogEntry) (emit bool, summary *LogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.statsFor(e.Severity)
+
+	if e.Severity == logging.Error || e.Severity == logging.Critical {
+		st.Emitted++
+		return true, nil
+	}
+
+	if rate, ok := s.rates[e.Severity]; ok && rate < 1 && rand.Float64() >= rate {
+		st.Sampled++
+		return false, nil
+	}
+
+	if !s.buckets[e.Severity].allow() {
+		st.Sampled++
+		return false, nil
+	}
+
+	if s.dupThreshold > 0 && s.dupWindow > 0 {
+		key := dupKey{severity: e.Severity, message: e.Message}
+		if e.Source != nil {
+			key.file = e.Source.File
+			key.line = e.Source.Line
+		}
+
+		now := time.Now()
+		w := s.dups[key]
+		if w == nil || now.Sub(w.start) > s.dupWindow {
+			if w != nil && w.count > s.dupThreshold {
+				summary = suppressedSummary(e, w.count-s.dupThreshold)
+			}
+			w = &dupWindow{start: now}
+			s.dups[key] = w
+		}
+		w.count++
+		suppressed := false
+		if w.count > s.dupThreshold {
+			st.Suppressed++
+			suppressed = true
+		}
+
+		s.sweepStaleDups(now)
+		if suppressed {
+			return false, summary
+		}
+	}
+
+	st.Emitted++
+	return true, summary
+}
+
+// sweepStaleDups removes dups entries whose window has closed (started
+// more than dupWindow ago), so a long-running process doesn't accumulate one
+// entry per distinct (severity, source, message) ever seen: without this,
+// a caller logging a unique message per call (e.g. an error string with an
+// embedded id) would grow sampler.dups forever. It scans at most once per
+// dupWindow, amortizing the cost over every allow() call in between.
+func (s *sampler) sweepStaleDups(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for key, w := range s.dups {
+		if now.Sub(w.start) > s.dupWindow {
+			delete(s.dups, key)
+		}
+	}
+	s.nextSweep = now.Add(s.dupWindow)
+}
+
+// suppressedSummary builds the periodic entry reported in place of the
+// occurrences collapsed by duplicate suppression.
+func suppressedSummary(e LogEntry, count int) *LogEntry {
+	return &LogEntry{
+		Message:  fmt.Sprintf("suppressed %d occurrences of: %s", count, e.Message),
+		Severity: e.Severity,
+		Labels:   map[string]string{"suppressed_count": strconv.Itoa(count)},
+		// This entry is synthesized by the sampler itself; it must always
+		// be emitted and must not recurse back into the sampler.
+		bypassSampler: true,
+	}
+}
+
+// Stats returns a snapshot of how many entries of each severity have been
+// emitted, dropped by sampling, or collapsed as duplicates since Init.
+func Stats() map[logging.Severity]SeverityStats {
+	out := make(map[logging.Severity]SeverityStats)
+	if activeSampler == nil {
+		return out
+	}
+	activeSampler.mu.Lock()
+	defer activeSampler.mu.Unlock()
+	for sev, st := range activeSampler.stats {
+		out[sev] = *st
+	}
+	return out
+}
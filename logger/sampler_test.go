@@ -0,0 +1,115 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	logpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+func TestSamplerBurstPerSecondDropsExcess(t *testing.T) {
+	s := newSampler(nil, map[logging.Severity]int{logging.Info: 2}, 0, 0)
+
+	var emitted int
+	for i := 0; i < 5; i++ {
+		if emit, _ := s.allow(LogEntry{Severity: logging.Info, Message: "hi"}); emit {
+			emitted++
+		}
+	}
+	if emitted != 2 {
+		t.Errorf("emitted = %d, want 2 (burst of 2 tokens, no refill within the loop)", emitted)
+	}
+}
+
+func TestSamplerNeverDropsErrorOrCritical(t *testing.T) {
+	s := newSampler(map[logging.Severity]float64{logging.Error: 0, logging.Critical: 0}, map[logging.Severity]int{logging.Error: 0, logging.Critical: 0}, 0, 0)
+
+	for _, sev := range []logging.Severity{logging.Error, logging.Critical} {
+		for i := 0; i < 10; i++ {
+			if emit, _ := s.allow(LogEntry{Severity: sev, Message: "boom"}); !emit {
+				t.Fatalf("call %d: %v entry was dropped, want it always emitted", i, sev)
+			}
+		}
+	}
+}
+
+func TestSamplerSuppressesDuplicatesAndSummarizes(t *testing.T) {
+	s := newSampler(nil, nil, 2, 50*time.Millisecond)
+	entry := LogEntry{Severity: logging.Info, Message: "retrying", Source: &logpb.LogEntrySourceLocation{File: "x.go", Line: 1}}
+
+	var suppressed int
+	for i := 0; i < 5; i++ {
+		if emit, _ := s.allow(entry); !emit {
+			suppressed++
+		}
+	}
+	if suppressed != 3 {
+		t.Errorf("suppressed = %d, want 3 (5 calls - threshold of 2)", suppressed)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	emit, summary := s.allow(entry)
+	if !emit {
+		t.Fatalf("first call of a new window was dropped, want it emitted")
+	}
+	if summary == nil {
+		t.Fatalf("want a suppressed-occurrences summary once the window rolls over")
+	}
+	if summary.Labels["suppressed_count"] != "3" {
+		t.Errorf("summary suppressed_count = %q, want %q", summary.Labels["suppressed_count"], "3")
+	}
+	if !summary.bypassSampler {
+		t.Errorf("summary entry must bypass the sampler to avoid being suppressed itself")
+	}
+}
+
+func TestSamplerSweepsStaleDupEntries(t *testing.T) {
+	s := newSampler(nil, nil, 2, 20*time.Millisecond)
+
+	for i := 0; i < 50; i++ {
+		msg := "unique-" + strconv.Itoa(i)
+		s.allow(LogEntry{Severity: logging.Info, Message: msg, Source: &logpb.LogEntrySourceLocation{File: "x.go", Line: 1}})
+	}
+	if len(s.dups) != 50 {
+		t.Fatalf("len(dups) = %d, want 50 before any window has elapsed", len(s.dups))
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	// One more call is needed to trigger the sweep (it runs inline with
+	// allow, not on a timer), using a key distinct from the 50 above. Its
+	// own fresh entry stays in dups, so one entry remains after the sweep.
+	s.allow(LogEntry{Severity: logging.Info, Message: "trigger-sweep", Source: &logpb.LogEntrySourceLocation{File: "x.go", Line: 1}})
+
+	if len(s.dups) >= 50 {
+		t.Errorf("len(dups) = %d, want the 50 stale entries evicted once their window elapsed", len(s.dups))
+	}
+}
+
+func TestStatsTracksEmittedAndSampled(t *testing.T) {
+	activeSampler = newSampler(nil, map[logging.Severity]int{logging.Info: 1}, 0, 0)
+	defer func() { activeSampler = nil }()
+
+	activeSampler.allow(LogEntry{Severity: logging.Info})
+	activeSampler.allow(LogEntry{Severity: logging.Info})
+
+	got := Stats()[logging.Info]
+	if got.Emitted != 1 || got.Sampled != 1 {
+		t.Errorf("Stats()[Info] = %+v, want Emitted=1 Sampled=1", got)
+	}
+}
@@ -0,0 +1,210 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// Sink receives every LogEntry that passes Log's sampling. Sinks are
+// independent of one another: each runs behind its own sinkRunner
+// goroutine, so a slow or blocked sink can neither delay Log nor hold up
+// any other sink.
+type Sink interface {
+	// Write delivers e to the sink. It is only ever called from the
+	// sink's own goroutine, so implementations don't need to be
+	// concurrency-safe with respect to themselves.
+	Write(ctx context.Context, e LogEntry) error
+	// Flush makes a best-effort attempt to persist any entries the sink
+	// has buffered.
+	Flush() error
+	// Close releases any resources held by the sink. It's called once,
+	// after the sink's goroutine has drained its queue or the close
+	// deadline passed, whichever comes first.
+	Close() error
+}
+
+// sinkQueueDepth bounds how many entries a sink may have buffered before
+// Log starts dropping entries destined for it instead of blocking.
+const sinkQueueDepth = 1000
+
+// sinkRunner drives a Sink from its own goroutine via a bounded channel.
+type sinkRunner struct {
+	name string
+	sink Sink
+
+	entries chan LogEntry
+	done    chan struct{}
+	dropped int64
+
+	// ctx is passed to every Write and is canceled once the close
+	// deadline elapses, so a sink whose Write respects ctx (as the
+	// built-in HTTP- and net.Conn-based sinks do) is interrupted rather
+	// than racing sinkRunner.close's Flush/Close against a Write still
+	// in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newSinkRunner(name string, sink Sink) *sinkRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &sinkRunner{
+		name:    name,
+		sink:    sink,
+		entries: make(chan LogEntry, sinkQueueDepth),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go r.run()
+	return r
+}
+
+func (r *sinkRunner) run() {
+	defer close(r.done)
+	for {
+		select {
+		case e, ok := <-r.entries:
+			if !ok {
+				return
+			}
+			if err := r.sink.Write(r.ctx, e); err != nil {
+				Debugf("logger: sink %s failed to write entry: %v", r.name, err)
+			}
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// write enqueues e for the sink. If the sink is falling behind, e is
+// dropped and counted rather than blocking the caller of Log.
+func (r *sinkRunner) write(e LogEntry) {
+	select {
+	case r.entries <- e:
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+	}
+}
+
+// close stops accepting new entries, waits for the backlog to drain (up
+// to deadline, after which any Write still in flight is canceled), then
+// flushes and closes the underlying sink. flush controls whether Flush is
+// attempted at all, so Close can still release a sink's resources (e.g. a
+// gRPC client) without trying to use an endpoint already known to be
+// unreachable.
+func (r *sinkRunner) close(deadline time.Time, flush bool) error {
+	close(r.entries)
+	timer := time.AfterFunc(time.Until(deadline), r.cancel)
+	<-r.done
+	timer.Stop()
+
+	var flushErr error
+	if flush {
+		flushErr = r.sink.Flush()
+	}
+	closeErr := r.sink.Close()
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// SinkStats reports, per sink, how many entries have been dropped because
+// the sink fell behind. Sinks with no drops are omitted.
+func SinkStats() map[string]int64 {
+	out := make(map[string]int64, len(sinkRunners))
+	for _, r := range sinkRunners {
+		if d := atomic.LoadInt64(&r.dropped); d > 0 {
+			out[r.name] = d
+		}
+	}
+	return out
+}
+
+// localSink delivers entries to the platform-specific local destination
+// (syslog, or eventlog and COM1 on Windows) configured by localSetup.
+type localSink struct{}
+
+func (localSink) Write(ctx context.Context, e LogEntry) error {
+	local(e)
+	return nil
+}
+func (localSink) Flush() error { return nil }
+func (localSink) Close() error { localClose(); return nil }
+
+// writerSink delivers entries to an arbitrary io.Writer, such as one of
+// LogOpts.Writers.
+type writerSink struct {
+	w io.Writer
+}
+
+func (s writerSink) Write(ctx context.Context, e LogEntry) error {
+	_, err := s.w.Write(e.bytes())
+	return err
+}
+func (writerSink) Flush() error { return nil }
+func (writerSink) Close() error { return nil }
+
+// cloudSink delivers entries to Cloud Logging.
+type cloudSink struct {
+	client *logging.Client
+	logger *logging.Logger
+}
+
+func (s cloudSink) Write(ctx context.Context, e LogEntry) error {
+	var payload interface{}
+	if e.StructuredPayload != nil {
+		payload = structuredPayloadWithMessage(e)
+	} else {
+		payload = e
+	}
+	s.logger.Log(logging.Entry{
+		Severity:       cloudSeverity(e.Severity),
+		SourceLocation: e.Source,
+		Payload:        payload,
+		Labels:         e.Labels,
+	})
+	return nil
+}
+func (s cloudSink) Flush() error { return s.logger.Flush() }
+func (s cloudSink) Close() error { return s.client.Close() }
+
+// structuredPayloadWithMessage copies e.StructuredPayload with e.Message
+// added under "message", so the jsonPayload Cloud Logging receives still
+// carries the human-written text instead of only the typed fields, which
+// otherwise appears nowhere once a structured payload is set.
+func structuredPayloadWithMessage(e LogEntry) map[string]interface{} {
+	payload := make(map[string]interface{}, len(e.StructuredPayload)+1)
+	for k, v := range e.StructuredPayload {
+		payload[k] = v
+	}
+	payload["message"] = e.Message
+	return payload
+}
+
+func cloudSeverity(sev logging.Severity) logging.Severity {
+	switch sev {
+	case Debug, Info, Warning, Error, Critical:
+		return sev
+	default:
+		return logging.Default
+	}
+}
@@ -0,0 +1,195 @@
+//  Copyright 2019 Google Inc. All Rights Reserved.
+//
+//  Licensed under the Apache License, Version 2.0 (the "License");
+//  you may not use this file except in compliance with the License.
+//  You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing, software
+//  distributed under the License is distributed on an "AS IS" BASIS,
+//  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//  See the License for the specific language governing permissions and
+//  limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+)
+
+// blockingSink never drains Write until release is closed, so tests can
+// force a sinkRunner's queue to fill up.
+type blockingSink struct {
+	release chan struct{}
+	writes  int
+	mu      sync.Mutex
+}
+
+func (s *blockingSink) Write(ctx context.Context, e LogEntry) error {
+	<-s.release
+	s.mu.Lock()
+	s.writes++
+	s.mu.Unlock()
+	return nil
+}
+func (s *blockingSink) Flush() error { return nil }
+func (s *blockingSink) Close() error { return nil }
+
+func TestSinkRunnerDropsWhenQueueFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	r := newSinkRunner("blocking", sink)
+	sinkRunners = []*sinkRunner{r}
+	defer func() { sinkRunners = nil }()
+
+	for i := 0; i < sinkQueueDepth+10; i++ {
+		r.write(LogEntry{Message: "hi"})
+	}
+	close(sink.release)
+	r.close(time.Now().Add(time.Second), true)
+
+	stats := SinkStats()
+	if stats["blocking"] == 0 {
+		t.Errorf("SinkStats()[\"blocking\"] = 0, want drops recorded once the queue filled up")
+	}
+}
+
+// recordingSink records every entry it receives, for asserting that queued
+// entries are drained and that Flush and Close are called.
+type recordingSink struct {
+	mu      sync.Mutex
+	entries []string
+	flushed bool
+	closed  bool
+}
+
+func (s *recordingSink) Write(ctx context.Context, e LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e.Message)
+	return nil
+}
+func (s *recordingSink) Flush() error { s.flushed = true; return nil }
+func (s *recordingSink) Close() error { s.closed = true; return nil }
+
+func TestSinkRunnerCloseDrainsThenFlushesAndCloses(t *testing.T) {
+	sink := &recordingSink{}
+	r := newSinkRunner("recording", sink)
+
+	r.write(LogEntry{Message: "one"})
+	r.write(LogEntry{Message: "two"})
+
+	if err := r.close(time.Now().Add(time.Second), true); err != nil {
+		t.Fatalf("close() = %v, want nil", err)
+	}
+	if len(sink.entries) != 2 {
+		t.Errorf("entries = %v, want [one two]", sink.entries)
+	}
+	if !sink.flushed || !sink.closed {
+		t.Errorf("flushed = %v, closed = %v, want both true", sink.flushed, sink.closed)
+	}
+}
+
+func TestOTelSeverityNumberMapping(t *testing.T) {
+	cases := []struct {
+		sev  logging.Severity
+		want int
+	}{
+		{Debug, 5},
+		{Info, 9},
+		{Warning, 13},
+		{Error, 17},
+		{Critical, 21},
+	}
+	for _, c := range cases {
+		if got := otelSeverityNumber(c.sev); got != c.want {
+			t.Errorf("otelSeverityNumber(%v) = %d, want %d", c.sev, got, c.want)
+		}
+	}
+}
+
+// blockingConn is a net.Conn whose Write hangs until SetDeadline is
+// called, simulating a peer that accepts a connection but never reads.
+type blockingConn struct {
+	net.Conn
+	unblock chan struct{}
+}
+
+func (c *blockingConn) Write(b []byte) (int, error) {
+	<-c.unblock
+	return 0, errors.New("i/o timeout")
+}
+func (c *blockingConn) SetDeadline(t time.Time) error {
+	select {
+	case <-c.unblock:
+	default:
+		close(c.unblock)
+	}
+	return nil
+}
+func (c *blockingConn) Close() error { return nil }
+
+func TestFluentForwardSinkWriteUnblocksOnCtxCancel(t *testing.T) {
+	conn := &blockingConn{unblock: make(chan struct{})}
+	s := &FluentForwardSink{addr: "unused", tag: "t", dialTimeout: time.Second, conn: conn}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Write(ctx, LogEntry{Message: "hi", Severity: Info}) }()
+
+	// Give the Write call time to reach conn.Write and block there.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Write() = nil, want an error once ctx is canceled mid-write")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write() did not return after ctx was canceled; a stalled peer would hang sinkRunner.close forever")
+	}
+}
+
+func TestStructuredPayloadWithMessageKeepsMessageText(t *testing.T) {
+	e := LogEntry{
+		Message:           "something happened",
+		StructuredPayload: map[string]interface{}{"attempt": 2},
+	}
+
+	got := structuredPayloadWithMessage(e)
+
+	want := map[string]interface{}{"attempt": 2, "message": "something happened"}
+	if len(got) != len(want) {
+		t.Fatalf("structuredPayloadWithMessage() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("structuredPayloadWithMessage()[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+	if e.StructuredPayload["message"] != nil {
+		t.Errorf("structuredPayloadWithMessage() mutated e.StructuredPayload: %v", e.StructuredPayload)
+	}
+}
+
+func TestMsgpackMapEncodesStringAndIntValues(t *testing.T) {
+	got := msgpackMap(map[string]interface{}{"a": "x", "b": int64(7)})
+
+	want := msgpackMapHeader(2)
+	want = append(want, msgpackString("a")...)
+	want = append(want, msgpackString("x")...)
+	want = append(want, msgpackString("b")...)
+	want = append(want, msgpackInt(7)...)
+
+	if string(got) != string(want) {
+		t.Errorf("msgpackMap() = %x, want %x", got, want)
+	}
+}